@@ -0,0 +1,72 @@
+// This file adds authentication and TLS termination to the embedded HTTP
+// signalling server: an optional `Authorization: Bearer` token, optional
+// mTLS via a client CA, and a "client-authorized" GStreamer signal (see
+// signals.go) so an embedding application can reject specific peers before
+// AddTrack is ever called.
+
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+)
+
+// authSettings mirrors the TLS/auth properties exposed on WebSink.
+type authSettings struct {
+	tlsCert         string
+	tlsKey          string
+	authBearerToken string
+	clientCA        string
+}
+
+func (a *authSettings) tlsEnabled() bool {
+	return a.tlsCert != "" && a.tlsKey != ""
+}
+
+// buildTLSConfig loads the server certificate and, if client-ca is set, a
+// client certificate pool for mTLS verification.
+func (a *authSettings) buildTLSConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(a.tlsCert, a.tlsKey)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	if a.clientCA != "" {
+		caBytes, err := os.ReadFile(a.clientCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, os.ErrInvalid
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
+
+// authorizeRequest checks the Authorization: Bearer header (when configured;
+// mTLS is enforced by the TLS server itself) and emits client-authorized,
+// writing a 401/403 response and returning false if the request is not
+// authorized.
+func (w *WebSink) authorizeRequest(resp http.ResponseWriter, req *http.Request) bool {
+	if token := w.settings.auth.authBearerToken; token != "" {
+		got := req.Header.Get("Authorization")
+		if subtle.ConstantTimeCompare([]byte(got), []byte("Bearer "+token)) != 1 {
+			http.Error(resp, "Unauthorized", http.StatusUnauthorized)
+			return false
+		}
+	}
+
+	if !w.emitClientAuthorized(req.Method, req.URL.Path, req.Header.Get("Authorization")) {
+		http.Error(resp, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+// This file adds TURN/ICE configuration parity with pion's SettingEngine:
+// a TURN server with credentials (or RFC 7635 / coturn shared-secret
+// ephemeral credentials), an ICE transport policy (all vs. relay-only), an
+// ephemeral UDP port range for restrictive firewalls / Kubernetes
+// deployments, ICE-TCP, and NAT 1:1 IP mapping.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// iceSettings mirrors the TURN/ICE properties exposed on WebSink.
+type iceSettings struct {
+	turnServer         string
+	turnUsername       string
+	turnPassword       string
+	turnSharedSecret   string // coturn static-auth-secret; mints ephemeral credentials when set
+	turnTTL            uint32 // lifetime in seconds for minted ephemeral credentials
+	iceTransportPolicy string // "all" or "relay"
+	iceUDPPortMin      uint16
+	iceUDPPortMax      uint16
+	iceTCPEnable       bool
+	nat1to1IPs         string // comma-separated
+}
+
+// ephemeralTURNCredential mints a time-limited coturn REST-API credential:
+// username is "<expiry-unix>:<userid>" and password is
+// base64(HMAC-SHA1(secret, username)), per the coturn static-auth-secret
+// scheme used for RFC 7635 style short-lived TURN credentials.
+func ephemeralTURNCredential(secret string, ttl uint32, now time.Time) (username, password string) {
+	username = strconv.FormatInt(now.Add(time.Duration(ttl)*time.Second).Unix(), 10) + ":websink"
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(username))
+	password = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return username, password
+}
+
+// buildICEServers returns the webrtc.Configuration.ICEServers list for the
+// configured STUN and TURN servers. When turn-shared-secret is set, the
+// TURN username/password are minted fresh (coturn ephemeral credentials)
+// rather than read from turn-username/turn-password.
+func (s *iceSettings) buildICEServers(stunServer string) []webrtc.ICEServer {
+	var servers []webrtc.ICEServer
+	if stunServer != "" {
+		servers = append(servers, webrtc.ICEServer{URLs: []string{stunServer}})
+	}
+	if s.turnServer != "" {
+		username, password := s.turnUsername, s.turnPassword
+		if s.turnSharedSecret != "" {
+			username, password = ephemeralTURNCredential(s.turnSharedSecret, s.turnTTL, time.Now())
+		}
+		servers = append(servers, webrtc.ICEServer{
+			URLs:           []string{s.turnServer},
+			Username:       username,
+			Credential:     password,
+			CredentialType: webrtc.ICECredentialTypePassword,
+		})
+	}
+	return servers
+}
+
+// transportPolicy maps the "ice-transport-policy" property to its pion enum.
+func (s *iceSettings) transportPolicy() webrtc.ICETransportPolicy {
+	if s.iceTransportPolicy == "relay" {
+		return webrtc.ICETransportPolicyRelay
+	}
+	return webrtc.ICETransportPolicyAll
+}
+
+// buildSettingEngine constructs the pion SettingEngine reflecting the
+// ephemeral UDP port range, NAT 1:1 IPs, and ICE-TCP mux settings.
+func (s *iceSettings) buildSettingEngine() (webrtc.SettingEngine, error) {
+	se := webrtc.SettingEngine{}
+
+	if s.iceUDPPortMin != 0 || s.iceUDPPortMax != 0 {
+		if err := se.SetEphemeralUDPPortRange(s.iceUDPPortMin, s.iceUDPPortMax); err != nil {
+			return se, fmt.Errorf("invalid ice-udp-port-min/max: %w", err)
+		}
+	}
+
+	if s.nat1to1IPs != "" {
+		ips := strings.Split(s.nat1to1IPs, ",")
+		for i := range ips {
+			ips[i] = strings.TrimSpace(ips[i])
+		}
+		se.SetNAT1To1IPs(ips, webrtc.ICECandidateTypeHost)
+	}
+
+	if s.iceTCPEnable {
+		listener, err := net.ListenTCP("tcp", &net.TCPAddr{Port: 0})
+		if err != nil {
+			return se, fmt.Errorf("failed to start ICE-TCP listener: %w", err)
+		}
+		tcpMux := webrtc.NewICETCPMux(nil, listener, 8)
+		se.SetICETCPMux(tcpMux)
+	}
+
+	return se, nil
+}
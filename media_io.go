@@ -0,0 +1,137 @@
+// This file adds bidirectional media support: sendrecv audio/video
+// transceivers so browsers can optionally publish a mic/cam back, a
+// reliable ordered "control" DataChannel for out-of-band app messages, and
+// per-peer appsrc pipelines that inbound tracks are routed into so incoming
+// media can be recorded or forwarded.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-gst/go-gst/gst"
+	"github.com/go-gst/go-gst/gst/app"
+	"github.com/pion/webrtc/v4"
+)
+
+// inboundPipelines tracks the per-peer GStreamer pipelines that inbound
+// audio/video tracks are appsrc'd into.
+type inboundPipelines struct {
+	mutex     sync.Mutex
+	pipelines map[string]*gst.Pipeline
+}
+
+// dataChannelHandlers holds the registered OnDataChannelMessage callbacks.
+type dataChannelHandlers struct {
+	mutex sync.RWMutex
+	fns   []func(peerID string, data []byte)
+}
+
+// OnDataChannelMessage registers a callback invoked for every message
+// received on a peer's "control" DataChannel, so downstream users can react
+// to client input (keyframe requests, bitrate hints, custom app events).
+func (w *WebSink) OnDataChannelMessage(fn func(peerID string, data []byte)) {
+	w.state.dcHandlers.mutex.Lock()
+	defer w.state.dcHandlers.mutex.Unlock()
+	w.state.dcHandlers.fns = append(w.state.dcHandlers.fns, fn)
+}
+
+func (w *WebSink) dispatchDataChannelMessage(peerID string, data []byte) {
+	w.state.dcHandlers.mutex.RLock()
+	defer w.state.dcHandlers.mutex.RUnlock()
+	for _, fn := range w.state.dcHandlers.fns {
+		fn(peerID, data)
+	}
+}
+
+// addControlDataChannel opens a reliable ordered DataChannel named "control"
+// on the peer connection and wires its messages to any registered
+// OnDataChannelMessage callbacks.
+func (w *WebSink) addControlDataChannel(peerID string, peerConnection *webrtc.PeerConnection) error {
+	ordered := true
+	dc, err := peerConnection.CreateDataChannel("control", &webrtc.DataChannelInit{Ordered: &ordered})
+	if err != nil {
+		return err
+	}
+	dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+		w.dispatchDataChannelMessage(peerID, msg.Data)
+	})
+	return nil
+}
+
+// addInboundTrackHandler wires OnTrack so that inbound audio/video from a
+// sendrecv peer is routed into a per-peer appsrc pipeline.
+func (w *WebSink) addInboundTrackHandler(peerID string, peerConnection *webrtc.PeerConnection) {
+	peerConnection.OnTrack(func(remoteTrack *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		pipelineDesc := fmt.Sprintf("appsrc name=src format=time is-live=true caps=%s ! %s",
+			rtpCapsForCodec(remoteTrack.Codec()), depayBranchFor(remoteTrack.Codec().MimeType))
+		pipeline, err := gst.NewPipelineFromString(pipelineDesc)
+		if err != nil {
+			CAT.LogError(fmt.Sprintf("Failed to create inbound pipeline for %s: %s", peerID, err.Error()))
+			return
+		}
+
+		w.state.inbound.mutex.Lock()
+		w.state.inbound.pipelines[peerID] = pipeline
+		w.state.inbound.mutex.Unlock()
+
+		srcElem, err := pipeline.GetElementByName("src")
+		if err != nil {
+			CAT.LogError("Failed to find appsrc in inbound pipeline: " + err.Error())
+			return
+		}
+		appSrc := app.SrcFromElement(srcElem)
+
+		pipeline.SetState(gst.StatePlaying)
+
+		buf := make([]byte, 1500)
+		for {
+			n, _, err := remoteTrack.Read(buf)
+			if err != nil {
+				pipeline.SetState(gst.StateNull)
+				w.state.inbound.mutex.Lock()
+				delete(w.state.inbound.pipelines, peerID)
+				w.state.inbound.mutex.Unlock()
+				return
+			}
+			// rtpXdepay elements expect whole RTP packets on an
+			// application/x-rtp-typed pad, not bare payload bytes, so the
+			// full packet read off the wire is what gets pushed and
+			// forwarded to websrc.
+			packet := append([]byte(nil), buf[:n]...)
+			appSrc.PushBuffer(gst.NewBufferFromBytes(packet))
+			pushInboundPayload(peerID, packet)
+		}
+	})
+}
+
+// rtpCapsForCodec builds the application/x-rtp caps string an appsrc needs
+// so the rtpXdepay element in depayBranchFor can find its pad template.
+func rtpCapsForCodec(codec webrtc.RTPCodecParameters) string {
+	media := "video"
+	if strings.HasPrefix(codec.MimeType, "audio/") {
+		media = "audio"
+	}
+	encodingName := strings.ToUpper(strings.TrimPrefix(codec.MimeType, media+"/"))
+	return fmt.Sprintf("application/x-rtp,media=%s,clock-rate=%d,encoding-name=%s", media, codec.ClockRate, encodingName)
+}
+
+// depayBranchFor returns the GStreamer depayloader/decoder branch for a
+// given negotiated codec MIME type, used to terminate inbound appsrc
+// pipelines.
+func depayBranchFor(mimeType string) string {
+	switch mimeType {
+	case webrtc.MimeTypeH264:
+		return "rtph264depay ! h264parse ! fakesink"
+	case webrtc.MimeTypeVP8:
+		return "rtpvp8depay ! fakesink"
+	case webrtc.MimeTypeVP9:
+		return "rtpvp9depay ! fakesink"
+	case webrtc.MimeTypeOpus:
+		return "rtpopusdepay ! opusdec ! fakesink"
+	default:
+		return "fakesink"
+	}
+}
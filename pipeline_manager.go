@@ -0,0 +1,221 @@
+// This file adds a PipelineManager that lazily creates a WebRTC track per
+// negotiated codec instead of the element hardcoding H264 at Start time, and
+// a --simulcast ladder flag for advertising multiple encoded layers to
+// subscribers.
+//
+// Note: websink is a sink-only element — it receives one already-encoded
+// elementary stream on its single sink pad and forwards samples verbatim.
+// A genuine simulcast ladder (separate x264enc/vp8enc branches off a tee)
+// requires re-encoding, which belongs upstream of this element. The ladder
+// below therefore gives each configured RID its own track, with every
+// layer's track fed identical samples (see websink.go's Render). Each
+// subscriber is sent one layer's track at a time, so its SDP answer carries
+// a single video track and no a=simulcast line.
+// What *is* genuine per-subscriber adaptation is which rung each subscriber
+// is pinned to: createPeerConnection starts it on the lowest one, and
+// maybeSwitchVideoLayer (rtcp_feedback.go) ReplaceTracks it onto a better
+// rung as that peer's own GCC estimate changes — no re-encoding needed for
+// that part, since switching is just pointing the sender at a different
+// already-live track.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// simulcastLayer describes one rung of a --simulcast ladder, e.g.
+// "720p:1.5Mbps", plus the per-layer track it's sent on once Start has run.
+type simulcastLayer struct {
+	rid        string
+	bitrateBps uint64
+	track      *webrtc.TrackLocalStaticSample
+}
+
+// peerVideoSelector tracks which ladder rung a given peer's video sender is
+// currently sending, so maybeSwitchVideoLayer can tell whether a fresh GCC
+// estimate actually calls for a ReplaceTrack.
+type peerVideoSelector struct {
+	sender *webrtc.RTPSender
+	layer  int
+}
+
+// pipelineManager lazily creates and caches a sample track per negotiated
+// codec MIME type.
+type pipelineManager struct {
+	mutex  sync.Mutex
+	tracks map[string]*webrtc.TrackLocalStaticSample
+	layers []simulcastLayer
+	// MIME type the current layer tracks were created for, so
+	// createSimulcastTracks can be called again from SetCaps once the real
+	// negotiated codec is known without re-creating (and orphaning already
+	// subscribed senders from) tracks that already match.
+	layersMimeType string
+	// Per-peer video sender + its current ladder rung, keyed by peer ID.
+	selectors map[string]*peerVideoSelector
+}
+
+// createSimulcastTracks creates one TrackLocalStaticSample per configured
+// layer, each tagged with its RID via WithRTPStreamID. It is a no-op if the
+// layers were already built for mimeType, so it's safe to call once with a
+// placeholder codec at Start and again from SetCaps once the sink pad's
+// caps name has negotiated the real one.
+func (pm *pipelineManager) createSimulcastTracks(mimeType string) error {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	if pm.layersMimeType == mimeType {
+		return nil
+	}
+	capability := webrtc.RTPCodecCapability{MimeType: mimeType, ClockRate: 90000}
+	for i := range pm.layers {
+		track, err := webrtc.NewTrackLocalStaticSample(
+			capability,
+			"video",
+			"websink",
+			webrtc.WithRTPStreamID(pm.layers[i].rid),
+		)
+		if err != nil {
+			return err
+		}
+		pm.layers[i].track = track
+	}
+	pm.layersMimeType = mimeType
+	return nil
+}
+
+// registerPeerSelector records the video sender a peer was just given and
+// the ladder rung its track came from, so a later GCC estimate can move it
+// to a different rung via maybeSwitchVideoLayer.
+func (pm *pipelineManager) registerPeerSelector(peerID string, sender *webrtc.RTPSender, layer int) {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	if pm.selectors == nil {
+		pm.selectors = make(map[string]*peerVideoSelector)
+	}
+	pm.selectors[peerID] = &peerVideoSelector{sender: sender, layer: layer}
+}
+
+// unregisterPeerSelector drops peerID's selector once its peer connection
+// closes, so maybeSwitchVideoLayer stops trying to ReplaceTrack a sender
+// that's gone.
+func (pm *pipelineManager) unregisterPeerSelector(peerID string) {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	delete(pm.selectors, peerID)
+}
+
+// selectLayerForBitrate returns the index into layers (ordered highest to
+// lowest bitrate, as parseSimulcastLadder produces them) of the highest
+// rung whose bitrateBps fits within bitsPerSecond, falling back to the
+// lowest rung if even that one doesn't fit.
+func selectLayerForBitrate(layers []simulcastLayer, bitsPerSecond int) int {
+	for i, layer := range layers {
+		if uint64(bitsPerSecond) >= layer.bitrateBps {
+			return i
+		}
+	}
+	return len(layers) - 1
+}
+
+func newPipelineManager() *pipelineManager {
+	return &pipelineManager{tracks: make(map[string]*webrtc.TrackLocalStaticSample)}
+}
+
+// trackForCodec returns the shared track for capability.MimeType, creating
+// it with the given RTPCodecCapability on first use.
+func (pm *pipelineManager) trackForCodec(capability webrtc.RTPCodecCapability) (*webrtc.TrackLocalStaticSample, error) {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+
+	if track, ok := pm.tracks[capability.MimeType]; ok {
+		return track, nil
+	}
+	track, err := webrtc.NewTrackLocalStaticSample(
+		capability,
+		"video",
+		"websink",
+	)
+	if err != nil {
+		return nil, err
+	}
+	pm.tracks[capability.MimeType] = track
+	return track, nil
+}
+
+// mimeTypeForCaps maps a negotiated sink pad caps name to a WebRTC codec MIME type.
+func mimeTypeForCaps(capsName string) string {
+	switch capsName {
+	case "video/x-h264":
+		return webrtc.MimeTypeH264
+	case "video/x-vp8":
+		return webrtc.MimeTypeVP8
+	case "video/x-vp9":
+		return webrtc.MimeTypeVP9
+	case "video/x-av1":
+		return webrtc.MimeTypeAV1
+	default:
+		return webrtc.MimeTypeH264
+	}
+}
+
+// rtpCodecCapabilityForCaps builds the RTPCodecCapability pion needs to
+// negotiate the right payload type for a negotiated sink pad caps name. All
+// of H264/VP8/VP9/AV1 use a 90kHz clock rate. H264's sprop-parameter-sets
+// fmtp isn't set here: this element's sink caps are byte-stream/AU-aligned,
+// meaning SPS/PPS travel in-band with each IDR rather than out-of-band in
+// caps, so there is nothing to lift into the fmtp line.
+func rtpCodecCapabilityForCaps(capsName string) webrtc.RTPCodecCapability {
+	return webrtc.RTPCodecCapability{
+		MimeType:  mimeTypeForCaps(capsName),
+		ClockRate: 90000,
+	}
+}
+
+// parseSimulcastLadder parses a flag of the form
+// "1080p:4Mbps,720p:1.5Mbps,360p:500kbps" into layers ordered highest to
+// lowest bitrate, the order selectLayerForBitrate and createPeerConnection
+// rely on.
+func parseSimulcastLadder(ladder string) ([]simulcastLayer, error) {
+	if ladder == "" {
+		return nil, nil
+	}
+	var layers []simulcastLayer
+	for _, entry := range strings.Split(ladder, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid simulcast layer %q, want rid:bitrate", entry)
+		}
+		bps, err := parseBitrate(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bitrate in layer %q: %w", entry, err)
+		}
+		layers = append(layers, simulcastLayer{rid: parts[0], bitrateBps: bps})
+	}
+	sort.SliceStable(layers, func(i, j int) bool { return layers[i].bitrateBps > layers[j].bitrateBps })
+	return layers, nil
+}
+
+// parseBitrate parses values like "4Mbps", "1.5Mbps", "500kbps" into bits per second.
+func parseBitrate(s string) (uint64, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "bps")
+	multiplier := uint64(1)
+	switch {
+	case strings.HasSuffix(s, "M"):
+		multiplier = 1_000_000
+		s = strings.TrimSuffix(s, "M")
+	case strings.HasSuffix(s, "k"):
+		multiplier = 1_000
+		s = strings.TrimSuffix(s, "k")
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(value * float64(multiplier)), nil
+}
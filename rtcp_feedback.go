@@ -0,0 +1,200 @@
+// This file adds per-peer RTCP feedback handling: PLI/FIR from subscribers
+// are forwarded upstream as a GstForceKeyUnit event so a newly-joined peer
+// doesn't have to wait up to a full GOP for its first frame, and REMB/TWCC
+// bandwidth estimates are exposed as the read-only "estimated-bitrate"
+// property so the upstream encoder (x264enc/vp8enc, outside this sink) can
+// be retuned by whoever built the pipeline.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-gst/go-gst/gst"
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/gcc"
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v4"
+)
+
+// newWebrtcAPI builds a *webrtc.API with the default codecs, an interceptor
+// registry that includes a GCC bandwidth estimator so REMB/TWCC feedback
+// can be turned into a bitrate recommendation, and the given SettingEngine
+// (ICE port range, NAT 1:1 IPs, ICE-TCP, ...). A fresh API (and therefore a
+// fresh gcc factory) is built per peer connection rather than shared, so
+// onBandwidthEstimate's closure can unambiguously attribute every estimate
+// to the one peer it was built for.
+func newWebrtcAPI(onBandwidthEstimate func(bitsPerSecond int), se webrtc.SettingEngine) (*webrtc.API, error) {
+	m := &webrtc.MediaEngine{}
+	if err := m.RegisterDefaultCodecs(); err != nil {
+		return nil, err
+	}
+
+	ir := &interceptor.Registry{}
+	if err := webrtc.RegisterDefaultInterceptors(m, ir); err != nil {
+		return nil, err
+	}
+
+	gccFactory, err := gcc.NewSendSideBWEInterceptor(gcc.WithInitialBitrate(1_000_000))
+	if err == nil {
+		last := time.Time{}
+		gccFactory.OnTargetBitrateChange(func(bitrate int) {
+			if since := time.Since(last); since < time.Second {
+				return
+			}
+			last = time.Now()
+			onBandwidthEstimate(bitrate)
+		})
+		ir.Add(gccFactory)
+	}
+
+	return webrtc.NewAPI(webrtc.WithMediaEngine(m), webrtc.WithInterceptorRegistry(ir), webrtc.WithSettingEngine(se)), nil
+}
+
+// watchKeyframeRequests reads RTCP from the given sender (createPeerConnection
+// passes the video sender) and forces a keyframe upstream whenever a PLI or
+// FIR arrives.
+func (w *WebSink) watchKeyframeRequests(peerID string, sender *webrtc.RTPSender) {
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, _, err := sender.Read(buf)
+			if err != nil {
+				return
+			}
+			packets, err := rtcp.Unmarshal(buf[:n])
+			if err != nil {
+				continue
+			}
+			for _, pkt := range packets {
+				switch pkt.(type) {
+				case *rtcp.PictureLossIndication, *rtcp.FullIntraRequest:
+					CAT.Log(gst.LevelInfo, "Keyframe requested by "+peerID)
+					w.requestKeyframe()
+				}
+			}
+		}
+	}()
+}
+
+// requestKeyframe sends a GstForceKeyUnit custom upstream event so the
+// encoder feeding this sink's pad produces an IDR on its next frame. PLI/FIR
+// from many peers arrive in bursts (e.g. everyone joining at once); the
+// keyframe-interval-ms property coalesces such a burst into at most one
+// upstream request per interval.
+func (w *WebSink) requestKeyframe() {
+	w.state.bufferMutex.Lock()
+	self := w.state.baseSink
+	interval := time.Duration(w.settings.keyframeIntervalMs) * time.Millisecond
+	if interval > 0 && time.Since(w.state.lastKeyframeRequest) < interval {
+		w.state.bufferMutex.Unlock()
+		return
+	}
+	w.state.lastKeyframeRequest = time.Now()
+	w.state.bufferMutex.Unlock()
+	if self == nil {
+		return
+	}
+	structure := gst.NewStructure("GstForceKeyUnit")
+	structure.SetValue("all-headers", true)
+	event := gst.NewEventCustom(gst.EventTypeCustomUpstream, structure)
+	self.Element().SendEvent(event)
+}
+
+// onPeerBitrateChange records peerID's latest GCC estimate, recomputes the
+// aggregate across all peers according to min-bitrate-mode, stores it for
+// the "estimated-bitrate" property, switches peerID's simulcast layer if the
+// new estimate calls for a different rung, and emits bitrate-changed (see
+// signals.go) so an embedding application can retune its encoder.
+func (w *WebSink) onPeerBitrateChange(peerID string, bitsPerSecond int) {
+	w.state.bufferMutex.Lock()
+	if w.state.peerBitrates == nil {
+		w.state.peerBitrates = make(map[string]int)
+	}
+	w.state.peerBitrates[peerID] = bitsPerSecond
+	aggregate := aggregateBitrate(w.state.peerBitrates, w.settings.minBitrateMode)
+	w.state.estimatedBitrate = aggregate
+	w.state.bufferMutex.Unlock()
+
+	w.maybeSwitchVideoLayer(peerID, bitsPerSecond)
+	w.emitBitrateChanged(peerID, bitsPerSecond, aggregate)
+}
+
+// maybeSwitchVideoLayer compares bitsPerSecond (peerID's latest GCC
+// estimate) against the simulcast ladder and, if a different rung now fits
+// better, ReplaceTracks peerID's video sender onto it. This is the adaptive
+// bitrate behavior the simulcast property promises: every layer's track is
+// fed identical samples (see Render), so switching is just pointing the
+// sender at a different already-live track.
+func (w *WebSink) maybeSwitchVideoLayer(peerID string, bitsPerSecond int) {
+	pm := w.state.pipelineManager
+	layers := pm.layers
+	if len(layers) == 0 {
+		return
+	}
+
+	pm.mutex.Lock()
+	selector, ok := pm.selectors[peerID]
+	if !ok {
+		pm.mutex.Unlock()
+		return
+	}
+	newLayer := selectLayerForBitrate(layers, bitsPerSecond)
+	if newLayer == selector.layer {
+		pm.mutex.Unlock()
+		return
+	}
+	selector.layer = newLayer
+	sender := selector.sender
+	track := layers[newLayer].track
+	pm.mutex.Unlock()
+
+	if track == nil {
+		return
+	}
+	if err := sender.ReplaceTrack(track); err != nil {
+		CAT.LogError(fmt.Sprintf("Failed to switch %s to simulcast layer %s: %s", peerID, layers[newLayer].rid, err.Error()))
+	}
+}
+
+// onPeerDisconnected drops peerID's bitrate estimate so a departed peer
+// doesn't keep dragging down a "min" aggregate.
+func (w *WebSink) onPeerDisconnected(peerID string) {
+	w.state.bufferMutex.Lock()
+	delete(w.state.peerBitrates, peerID)
+	w.state.bufferMutex.Unlock()
+}
+
+// aggregateBitrate combines per-peer estimates according to mode ("min",
+// "max", or "average", defaulting to "min" since the slowest peer is what
+// an upstream encoder shared by all peers must not exceed).
+func aggregateBitrate(perPeer map[string]int, mode string) int {
+	if len(perPeer) == 0 {
+		return 0
+	}
+	switch mode {
+	case "max":
+		max := 0
+		for _, bps := range perPeer {
+			if bps > max {
+				max = bps
+			}
+		}
+		return max
+	case "average":
+		total := 0
+		for _, bps := range perPeer {
+			total += bps
+		}
+		return total / len(perPeer)
+	default: // "min"
+		min := -1
+		for _, bps := range perPeer {
+			if min == -1 || bps < min {
+				min = bps
+			}
+		}
+		return min
+	}
+}
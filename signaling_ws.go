@@ -0,0 +1,128 @@
+// This file adds WebSocket-based trickle ICE signaling at /ws, matching the
+// neko client protocol, so first-frame latency no longer has to pay for a
+// full ICE gathering pass before the answer is sent. The existing
+// /api/session handler is kept alongside it; a client picks /ws only by
+// connecting to it, nothing here redirects /api/session clients to /ws.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/go-gst/go-gst/gst"
+	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v4"
+)
+
+var wsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// wsSignalMessage is the envelope for every message exchanged over /ws.
+type wsSignalMessage struct {
+	Event string          `json:"event"`
+	SDP   string          `json:"sdp,omitempty"`
+	Lite  bool            `json:"lite,omitempty"`
+	ICE   []string        `json:"ice,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// handleWS upgrades the connection and runs the signal/provide,
+// signal/candidate, signal/answer exchange for one peer.
+func (w *WebSink) handleWS(resp http.ResponseWriter, req *http.Request) {
+	// A ?room= query selects which named stream's peer connection this
+	// socket negotiates, so several websink instances in one process can
+	// share the room's signalling endpoint.
+	if room := req.URL.Query().Get("room"); room != "" && room != w.settings.streamName {
+		target, ok := globalStreamRegistry.lookup(room)
+		if !ok {
+			http.Error(resp, "No such room: "+room, http.StatusNotFound)
+			return
+		}
+		w = target
+	}
+
+	if !w.authorizeRequest(resp, req) {
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(resp, req, nil)
+	if err != nil {
+		CAT.LogError("WebSocket upgrade failed: " + err.Error())
+		return
+	}
+	defer conn.Close()
+
+	// gorilla/websocket allows exactly one concurrent writer per connection;
+	// OnICECandidate below fires from pion's own goroutine while the read
+	// loop at the bottom of this function writes the answer, so every write
+	// to conn must go through this mutex.
+	var writeMutex sync.Mutex
+	writeJSON := func(v any) error {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		return conn.WriteJSON(v)
+	}
+
+	peerID := fmt.Sprintf("ws-peer-%d", w.state.sessionNext.Add(1))
+	peerConnection, err := w.createPeerConnection(peerID)
+	if err != nil {
+		CAT.LogError("Error creating peer connection: " + err.Error())
+		return
+	}
+	defer peerConnection.Close()
+
+	peerConnection.OnICECandidate(func(candidate *webrtc.ICECandidate) {
+		if candidate == nil {
+			return
+		}
+		payload, err := json.Marshal(candidate.ToJSON())
+		if err != nil {
+			return
+		}
+		writeJSON(wsSignalMessage{Event: "signal/candidate", Data: payload})
+	})
+
+	w.updatePeerConnections(peerID, peerConnection, true)
+	defer w.updatePeerConnections(peerID, nil, false)
+
+	for {
+		var msg wsSignalMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+
+		switch msg.Event {
+		case "signal/provide":
+			offer := webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: msg.SDP}
+			if err := peerConnection.SetRemoteDescription(offer); err != nil {
+				CAT.LogError("Error setting remote description: " + err.Error())
+				return
+			}
+			answer, err := peerConnection.CreateAnswer(nil)
+			if err != nil {
+				CAT.LogError("Error creating answer: " + err.Error())
+				return
+			}
+			if err := peerConnection.SetLocalDescription(answer); err != nil {
+				CAT.LogError("Error setting local description: " + err.Error())
+				return
+			}
+			writeJSON(wsSignalMessage{Event: "signal/answer", SDP: peerConnection.LocalDescription().SDP})
+		case "signal/candidate":
+			var candidate webrtc.ICECandidateInit
+			if err := json.Unmarshal(msg.Data, &candidate); err != nil {
+				CAT.LogError("Error parsing remote candidate: " + err.Error())
+				continue
+			}
+			if err := peerConnection.AddICECandidate(candidate); err != nil {
+				CAT.LogError("Error adding remote candidate: " + err.Error())
+			}
+		default:
+			CAT.Log(gst.LevelWarning, "Unknown /ws signal event: "+msg.Event)
+		}
+	}
+}
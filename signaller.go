@@ -0,0 +1,131 @@
+// This file decouples signalling transport from the media plane behind a
+// `signalling-mode` property: "embedded" (the default, today's local
+// http.Server), and "external", where the element does no HTTP of its own
+// and instead exposes CreateExternalSession/SubmitExternalICECandidate so an
+// embedding application can feed SDP through its own signalling channel
+// (Matrix, XMPP, a custom gRPC service, ...).
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-gst/go-gst/gst"
+	"github.com/go-gst/go-gst/gst/base"
+	"github.com/pion/webrtc/v4"
+)
+
+const (
+	signallingModeEmbedded = "embedded"
+	signallingModeExternal = "external"
+)
+
+// Signaller decouples a signalling transport's lifecycle from the media
+// plane, mirroring gst-plugins-rs webrtcsink's signaller object. Both modes
+// below drive peer connections through the same createPeerConnection path;
+// HandleSessionRequested/HandleSDP are satisfied today by handleSession,
+// handleWS, and CreateExternalSession directly rather than through a second
+// dispatch layer, since those already cover the HTTP, WebSocket, and
+// external-callback transports this element supports.
+type Signaller interface {
+	// Start is called from WebSink.Start once the shared tracks exist.
+	Start(self *base.GstBaseSink) bool
+	// Stop tears down the transport; peer connections are closed separately.
+	Stop()
+}
+
+// embeddedSignaller is the Signaller for signalling-mode "embedded": it owns
+// the HTTP server that serves /api/session, /whip, /whep, and /ws.
+type embeddedSignaller struct {
+	w *WebSink
+}
+
+func (s *embeddedSignaller) Start(self *base.GstBaseSink) bool {
+	return s.w.startHTTPServer(self)
+}
+
+func (s *embeddedSignaller) Stop() {
+	if s.w.state.server != nil {
+		s.w.state.server.Close()
+	}
+}
+
+// externalSignaller is the Signaller for signalling-mode "external": it
+// starts nothing, leaving CreateExternalSession/SubmitExternalICECandidate
+// as the embedding application's only way to drive peer connections.
+type externalSignaller struct {
+	w *WebSink
+}
+
+func (s *externalSignaller) Start(self *base.GstBaseSink) bool {
+	self.Log(CAT, gst.LevelInfo, "Signalling mode is external; not starting the embedded HTTP server")
+	return true
+}
+
+func (s *externalSignaller) Stop() {}
+
+// newSignaller picks the Signaller implementation for the configured mode.
+func newSignaller(w *WebSink) Signaller {
+	if w.settings.signallingMode == signallingModeExternal {
+		return &externalSignaller{w: w}
+	}
+	return &embeddedSignaller{w: w}
+}
+
+// CreateExternalSession is the external-signalling equivalent of
+// handleSession: an embedding application calls this with an SDP offer it
+// received through its own channel and gets back the SDP answer to send
+// back. Only valid when signalling-mode is "external". Reachable from
+// outside this process via the "offer" action signal (see signals.go).
+func (w *WebSink) CreateExternalSession(peerID, offerSDP string) (string, error) {
+	if w.settings.signallingMode != signallingModeExternal {
+		return "", fmt.Errorf("signalling-mode is %q, not %q", w.settings.signallingMode, signallingModeExternal)
+	}
+
+	peerConnection, err := w.createPeerConnection(peerID)
+	if err != nil {
+		return "", err
+	}
+
+	offer := webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: offerSDP}
+	if err := peerConnection.SetRemoteDescription(offer); err != nil {
+		peerConnection.Close()
+		return "", err
+	}
+
+	answer, err := peerConnection.CreateAnswer(nil)
+	if err != nil {
+		peerConnection.Close()
+		return "", err
+	}
+	if err := peerConnection.SetLocalDescription(answer); err != nil {
+		peerConnection.Close()
+		return "", err
+	}
+
+	gatherComplete := webrtc.GatheringCompletePromise(peerConnection)
+	<-gatherComplete
+
+	w.updatePeerConnections(peerID, peerConnection, true)
+	return peerConnection.LocalDescription().SDP, nil
+}
+
+// SubmitExternalICECandidate feeds a remote ICE candidate received through
+// external signalling to the named peer's connection. Reachable from
+// outside this process via the "ice-candidate" action signal (see signals.go).
+func (w *WebSink) SubmitExternalICECandidate(peerID string, candidate webrtc.ICECandidateInit) error {
+	w.state.peerConnectionsMutex.RLock()
+	peerConnection, ok := w.state.peerConnections[peerID]
+	w.state.peerConnectionsMutex.RUnlock()
+	if !ok {
+		return fmt.Errorf("no such peer: %s", peerID)
+	}
+	return peerConnection.AddICECandidate(candidate)
+}
+
+// maybeStartSignalling builds and starts this element's Signaller for the
+// configured signalling-mode, stashing it in state so Stop can tear it down.
+func (w *WebSink) maybeStartSignalling(self *base.GstBaseSink) bool {
+	w.state.signaller = newSignaller(w)
+	return w.state.signaller.Start(self)
+}
@@ -0,0 +1,112 @@
+// This file registers the GObject signals that let a non-Go embedding
+// application (C, Python, gst-launch, ...) reach behavior that is otherwise
+// only exposed as Go methods on *WebSink with no way for such a consumer to
+// ever obtain a *WebSink reference: package main with -buildmode c-shared
+// exports no //export'd C functions, so CreateExternalSession and
+// SubmitExternalICECandidate are unreachable from outside this process
+// except through these signals. client-authorized and bitrate-changed are connect-and-listen
+// signals; offer and ice-candidate are action signals, invoked the same way
+// appsrc's "push-sample" is invoked rather than connected to.
+
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/go-gst/go-glib/glib"
+	"github.com/pion/webrtc/v4"
+)
+
+const (
+	signalClientAuthorized = "client-authorized"
+	signalBitrateChanged   = "bitrate-changed"
+	signalOffer            = "offer"
+	signalICECandidate     = "ice-candidate"
+)
+
+// signals lists the GObject signals installed on the WebSink class, mirroring
+// how the properties slice in websink.go is installed via InstallProperties.
+var signals = []*glib.SignalSpec{
+	// client-authorized is emitted for every signalling request before a peer
+	// connection is created. Its default handler allows the request, so
+	// connecting nothing preserves today's default-allow behavior; a
+	// connected handler can return FALSE to reject the request with 403.
+	glib.NewSignal(
+		signalClientAuthorized, "Client Authorized",
+		"Emitted to authorize a signalling request; return FALSE to reject it with 403",
+		glib.SignalRunLast,
+		func(w *WebSink, method, path, authHeader string) bool { return true },
+	),
+	// bitrate-changed is emitted when a peer's GCC estimate changes, at most
+	// once per second per peer (estimates arriving sooner after the last one
+	// are dropped, see newWebrtcAPI), carrying that peer's own estimate and
+	// the aggregate across all peers
+	// (per min-bitrate-mode) so an upstream encoder can be retuned.
+	glib.NewSignal(
+		signalBitrateChanged, "Bitrate Changed",
+		"Emitted when a peer's estimated bitrate changes",
+		glib.SignalRunLast,
+		func(w *WebSink, peerID string, targetBps, aggregateBps int) {},
+	),
+	// offer is an action signal wrapping CreateExternalSession: an embedding
+	// application using signalling-mode=external invokes it with an SDP
+	// offer received through its own channel and gets back the SDP answer.
+	glib.NewSignal(
+		signalOffer, "Offer",
+		"Action signal: submit an external SDP offer for peerID, returns the SDP answer",
+		glib.SignalRunLast|glib.SignalAction,
+		func(w *WebSink, peerID, offerSDP string) string {
+			answer, err := w.CreateExternalSession(peerID, offerSDP)
+			if err != nil {
+				CAT.LogError("offer signal for " + peerID + ": " + err.Error())
+				return ""
+			}
+			return answer
+		},
+	),
+	// ice-candidate is an action signal wrapping SubmitExternalICECandidate:
+	// an embedding application invokes it with a JSON-encoded
+	// webrtc.ICECandidateInit received through its own signalling channel.
+	glib.NewSignal(
+		signalICECandidate, "ICE Candidate",
+		"Action signal: submit an external ICE candidate (JSON) for peerID, returns success",
+		glib.SignalRunLast|glib.SignalAction,
+		func(w *WebSink, peerID, candidateJSON string) bool {
+			var candidate webrtc.ICECandidateInit
+			if err := json.Unmarshal([]byte(candidateJSON), &candidate); err != nil {
+				CAT.LogError("ice-candidate signal for " + peerID + ": invalid candidate JSON: " + err.Error())
+				return false
+			}
+			if err := w.SubmitExternalICECandidate(peerID, candidate); err != nil {
+				CAT.LogError("ice-candidate signal for " + peerID + ": " + err.Error())
+				return false
+			}
+			return true
+		},
+	),
+}
+
+// emitClientAuthorized emits client-authorized for an incoming signalling
+// request and returns its result, defaulting to true (allow) if the signal
+// can't be emitted yet (no element instance, e.g. before Start has run).
+func (w *WebSink) emitClientAuthorized(method, path, authHeader string) bool {
+	self := w.state.baseSink
+	if self == nil {
+		return true
+	}
+	result := self.Element().Emit(signalClientAuthorized, method, path, authHeader)
+	authorized, ok := result.(bool)
+	if !ok {
+		return true
+	}
+	return authorized
+}
+
+// emitBitrateChanged emits bitrate-changed for peerID's latest GCC estimate.
+func (w *WebSink) emitBitrateChanged(peerID string, targetBps, aggregateBps int) {
+	self := w.state.baseSink
+	if self == nil {
+		return
+	}
+	self.Element().Emit(signalBitrateChanged, peerID, targetBps, aggregateBps)
+}
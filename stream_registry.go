@@ -0,0 +1,129 @@
+// This file generalizes the single global video/audio track pair into a
+// process-wide StreamRegistry keyed by stream name, so operators running
+// several websink elements in one process (each fed by its own
+// `--stream name=src`-style GStreamer source pipeline upstream) can address
+// them individually over HTTP as /api/session/{name} and /whep/{name}, and
+// inspect them all via GET /api/streams.
+//
+// Each WebSink instance still owns exactly one sink pad and therefore
+// exactly one stream; the registry's job is purely bookkeeping and routing
+// across however many WebSink elements exist in the process.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// streamRuntimeInfo is what a running WebSink instance publishes about
+// itself to the registry.
+type streamRuntimeInfo struct {
+	name      string
+	sink      *WebSink
+	startTime time.Time
+}
+
+// streamInfo is the JSON-serializable snapshot returned by GET /api/streams.
+type streamInfo struct {
+	Name        string `json:"name"`
+	Codec       string `json:"codec"`
+	Subscribers int    `json:"subscribers"`
+	UptimeSecs  int64  `json:"uptimeSeconds"`
+}
+
+// streamRegistry tracks every currently-started WebSink instance in this
+// process, keyed by its configured stream name.
+type streamRegistry struct {
+	mutex   sync.RWMutex
+	streams map[string]*streamRuntimeInfo
+}
+
+var globalStreamRegistry = &streamRegistry{streams: make(map[string]*streamRuntimeInfo)}
+
+func (r *streamRegistry) register(name string, sink *WebSink) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.streams[name] = &streamRuntimeInfo{name: name, sink: sink, startTime: time.Now()}
+}
+
+func (r *streamRegistry) unregister(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.streams, name)
+}
+
+func (r *streamRegistry) lookup(name string) (*WebSink, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	entry, ok := r.streams[name]
+	if !ok {
+		return nil, false
+	}
+	return entry.sink, true
+}
+
+func (r *streamRegistry) snapshot() []streamInfo {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	infos := make([]streamInfo, 0, len(r.streams))
+	for _, entry := range r.streams {
+		entry.sink.state.peerConnectionsMutex.RLock()
+		subscribers := len(entry.sink.state.peerConnections)
+		entry.sink.state.peerConnectionsMutex.RUnlock()
+
+		codec := ""
+		if track := entry.sink.state.videoTrack; track != nil {
+			codec = track.Codec().MimeType
+		}
+
+		infos = append(infos, streamInfo{
+			Name:        entry.name,
+			Codec:       codec,
+			Subscribers: subscribers,
+			UptimeSecs:  int64(time.Since(entry.startTime).Seconds()),
+		})
+	}
+	return infos
+}
+
+// handleStreams implements GET /api/streams, returning dashboard metadata
+// for every named stream currently running in this process.
+func (w *WebSink) handleStreams(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !w.authorizeRequest(resp, req) {
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(resp).Encode(globalStreamRegistry.snapshot())
+}
+
+// streamNameFromRequest returns the {name} path segment for routes
+// registered as ".../{name}", or the empty string for the un-suffixed route.
+func streamNameFromRequest(req *http.Request) string {
+	return req.PathValue("name")
+}
+
+// resolveNamedStream looks at the optional {name} path segment of req and
+// returns the WebSink instance that owns that stream. The un-suffixed
+// routes (no {name}) are always served by the instance that received the
+// request. Writes a 404 and returns ok=false if the named stream isn't
+// registered.
+func (w *WebSink) resolveNamedStream(resp http.ResponseWriter, req *http.Request) (*WebSink, bool) {
+	name := streamNameFromRequest(req)
+	if name == "" || name == w.settings.streamName {
+		return w, true
+	}
+	target, ok := globalStreamRegistry.lookup(name)
+	if !ok {
+		http.Error(resp, "No such stream: "+name, http.StatusNotFound)
+		return nil, false
+	}
+	return target, true
+}
@@ -38,6 +38,8 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-gst/go-glib/glib"
 	"github.com/go-gst/go-gst/gst"
@@ -48,8 +50,13 @@ import (
 
 // defaults:
 var (
-	DefaultPort       = 8091
-	DefaultStunServer = "stun:stun.l.google.com:19302"
+	DefaultPort               = 8091
+	DefaultStunServer         = "stun:stun.l.google.com:19302"
+	DefaultSimulcastLadder    = ""
+	DefaultStreamName         = "default"
+	DefaultSignallingMode     = signallingModeEmbedded
+	DefaultICETransportPolicy = "all"
+	DefaultMinBitrateMode     = "min"
 	// print colors
 	GREEN = "\033[32m"
 	RED   = "\033[31m"
@@ -94,6 +101,124 @@ var properties = []*glib.ParamSpec{
 		false,
 		glib.ParameterReadWrite,
 	),
+	glib.NewStringParam(
+		"simulcast", "Simulcast Ladder",
+		"Comma-separated rid:bitrate layers, e.g. 1080p:4Mbps,720p:1.5Mbps,360p:500kbps (empty to disable). "+
+			"NOTE: websink has a single encoded input stream, so every layer carries identical bytes; this is not a "+
+			"real multi-bitrate encode (see pipelineManager docs). Each subscriber is nonetheless started on the "+
+			"lowest rung and ReplaceTrack'd onto whichever rung best fits its own GCC bandwidth estimate as it "+
+			"changes, so subscribers do adapt individually even though the underlying bytes don't vary per rung.",
+		&DefaultSimulcastLadder,
+		glib.ParameterReadWrite,
+	),
+	glib.NewIntParam(
+		"estimated-bitrate", "Estimated Bitrate",
+		"Latest GCC/REMB/TWCC bandwidth estimate across all peers, in bits per second",
+		0, 1<<30, 0,
+		glib.ParameterReadable,
+	),
+	glib.NewStringParam(
+		"stream-name", "Stream Name",
+		"Name this stream is addressable as under /api/session/{name} and /whep/{name}",
+		&DefaultStreamName,
+		glib.ParameterReadWrite,
+	),
+	glib.NewStringParam(
+		"signalling-mode", "Signalling Mode",
+		"\"embedded\" runs the built-in HTTP server (default); \"external\" does no HTTP and expects the "+
+			"embedding application to drive CreateExternalSession/SubmitExternalICECandidate",
+		&DefaultSignallingMode,
+		glib.ParameterReadWrite,
+	),
+	glib.NewStringParam(
+		"turn-server", "TURN Server", "TURN server URL to use for WebRTC (empty for none)",
+		nil,
+		glib.ParameterReadWrite,
+	),
+	glib.NewStringParam(
+		"turn-username", "TURN Username", "Username for the TURN server",
+		nil,
+		glib.ParameterReadWrite,
+	),
+	glib.NewStringParam(
+		"turn-password", "TURN Password", "Credential for the TURN server",
+		nil,
+		glib.ParameterReadWrite,
+	),
+	glib.NewStringParam(
+		"turn-shared-secret", "TURN Shared Secret",
+		"coturn static-auth-secret; when set, websink mints a time-limited username/password pair "+
+			"(RFC 7635 / coturn REST API style) instead of using turn-username/turn-password",
+		nil,
+		glib.ParameterReadWrite,
+	),
+	glib.NewIntParam(
+		"turn-ttl", "TURN Credential TTL",
+		"Lifetime in seconds for credentials minted from turn-shared-secret",
+		1, 86400, 3600,
+		glib.ParameterReadWrite,
+	),
+	glib.NewStringParam(
+		"ice-transport-policy", "ICE Transport Policy", "\"all\" or \"relay\"",
+		&DefaultICETransportPolicy,
+		glib.ParameterReadWrite,
+	),
+	glib.NewIntParam(
+		"ice-udp-port-min", "ICE UDP Port Min", "Lower bound of the ephemeral UDP port range (0 for OS-assigned)",
+		0, 65535, 0,
+		glib.ParameterReadWrite,
+	),
+	glib.NewIntParam(
+		"ice-udp-port-max", "ICE UDP Port Max", "Upper bound of the ephemeral UDP port range (0 for OS-assigned)",
+		0, 65535, 0,
+		glib.ParameterReadWrite,
+	),
+	glib.NewBoolParam(
+		"ice-tcp-enable", "ICE TCP Enable", "Whether to also listen for ICE-TCP candidates",
+		false,
+		glib.ParameterReadWrite,
+	),
+	glib.NewStringParam(
+		"nat-1to1-ips", "NAT 1:1 IPs", "Comma-separated external IPs to advertise for 1:1 NAT mapping",
+		nil,
+		glib.ParameterReadWrite,
+	),
+	glib.NewStringParam(
+		"tls-cert", "TLS Certificate", "Path to a PEM certificate; set together with tls-key to serve HTTPS instead of HTTP",
+		nil,
+		glib.ParameterReadWrite,
+	),
+	glib.NewStringParam(
+		"tls-key", "TLS Key", "Path to the PEM private key matching tls-cert",
+		nil,
+		glib.ParameterReadWrite,
+	),
+	glib.NewStringParam(
+		"auth-bearer-token", "Auth Bearer Token",
+		"If set, signalling requests must carry a matching \"Authorization: Bearer <token>\" header",
+		nil,
+		glib.ParameterReadWrite,
+	),
+	glib.NewStringParam(
+		"client-ca", "Client CA",
+		"Path to a PEM CA bundle; if set, requires and verifies a client certificate signed by it (mTLS)",
+		nil,
+		glib.ParameterReadWrite,
+	),
+	glib.NewStringParam(
+		"min-bitrate-mode", "Bitrate Aggregation Mode",
+		"How per-peer GCC estimates are combined into \"estimated-bitrate\": \"min\" (default, safe for a shared "+
+			"encoder), \"max\", or \"average\"",
+		&DefaultMinBitrateMode,
+		glib.ParameterReadWrite,
+	),
+	glib.NewIntParam(
+		"keyframe-interval-ms", "Keyframe Interval",
+		"Minimum milliseconds between upstream keyframe requests, coalescing a burst of PLI/FIR from many peers "+
+			"into at most one GstForceKeyUnit event per interval (0 disables throttling)",
+		0, 60000, 0,
+		glib.ParameterReadWrite,
+	),
 }
 
 // Here we declare a private struct to hold our internal state.
@@ -109,22 +234,54 @@ type state struct {
 	// Map to store active peer connections
 	peerConnectionsMutex sync.RWMutex
 	peerConnections      map[string]*webrtc.PeerConnection
+	// Monotonic counter for /api/session peer IDs
+	sessionNext atomic.Uint64
 	// Channel to notify about peer connection changes
 	unblock chan int
 	// Shared video track
 	videoTrack *webrtc.TrackLocalStaticSample
+	// Shared audio track; nothing in this element writes samples to it
+	audioTrack *webrtc.TrackLocalStaticSample
 	// Buffer for H264 data
 	h264Buffer []byte
 	// Mutex for buffer access
 	bufferMutex sync.Mutex
+	// Registered OnDataChannelMessage callbacks for the "control" DataChannel
+	dcHandlers dataChannelHandlers
+	// Per-peer pipelines that inbound audio/video tracks are routed into
+	inbound inboundPipelines
+	// Manages lazily-created per-codec tracks and the simulcast ladder
+	pipelineManager *pipelineManager
+	// SettingEngine built at Start from settings.ice; reused to build a
+	// fresh per-peer *webrtc.API in createPeerConnection
+	settingEngine webrtc.SettingEngine
+	// Latest GCC bandwidth estimate per peer ID
+	peerBitrates map[string]int
+	// The GstBaseSink, kept so RTCP feedback can send events upstream and
+	// signals.go can emit GObject signals on the element
+	baseSink *base.GstBaseSink
+	// Latest GCC bandwidth estimate in bits per second
+	estimatedBitrate int
+	// The active Signaller for this element's signalling-mode
+	signaller Signaller
+	// Timestamp of the last upstream GstForceKeyUnit event, for
+	// keyframe-interval-ms throttling
+	lastKeyframeRequest time.Time
 }
 
 // This is another private struct where we hold the parameter values set on our element.
 type settings struct {
-	port       int
-	stunServer string
-	isLive     bool
-	unlock     bool
+	port               int
+	stunServer         string
+	isLive             bool
+	unlock             bool
+	simulcastLadder    string
+	streamName         string
+	signallingMode     string
+	ice                iceSettings
+	auth               authSettings
+	minBitrateMode     string
+	keyframeIntervalMs int
 }
 
 //go:embed static/*
@@ -136,6 +293,8 @@ type WebSink struct {
 	settings *settings
 	// The current state of the element
 	state *state
+	// WHIP/WHEP resource tracking
+	whipWhep *whipWhep
 }
 
 // updatePeerConnections adds or removes a peer connection from the global map
@@ -197,6 +356,15 @@ func (w *WebSink) handleSession(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	w, ok := w.resolveNamedStream(resp, req)
+	if !ok {
+		return
+	}
+
+	if !w.authorizeRequest(resp, req) {
+		return
+	}
+
 	// Read the request body
 	body, err := io.ReadAll(req.Body)
 	if err != nil {
@@ -211,8 +379,10 @@ func (w *WebSink) handleSession(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// Generate a unique ID for this peer connection
-	peerID := fmt.Sprintf("peer-%d", len(w.state.peerConnections)+1)
+	// Generate a unique ID for this peer connection. Counting live
+	// connections raced multiple simultaneous /api/session requests into
+	// the same peerID, so draw from a monotonic counter instead.
+	peerID := fmt.Sprintf("peer-%d", w.state.sessionNext.Add(1))
 
 	// Create a new peer connection for this client
 	peerConnection, err := w.createPeerConnection(peerID)
@@ -280,10 +450,23 @@ func (w *WebSink) handleSession(resp http.ResponseWriter, req *http.Request) {
 	json.NewEncoder(resp).Encode(response)
 }
 
+// newPeerAPI builds a fresh *webrtc.API for one peer connection, so its GCC
+// bandwidth estimates can be attributed to peerID unambiguously.
+func (w *WebSink) newPeerAPI(peerID string) (*webrtc.API, error) {
+	return newWebrtcAPI(func(bitsPerSecond int) {
+		w.onPeerBitrateChange(peerID, bitsPerSecond)
+	}, w.state.settingEngine)
+}
+
 // createPeerConnection creates a new peer connection with the shared tracks
 func (w *WebSink) createPeerConnection(peerID string) (*webrtc.PeerConnection, error) {
-	// Create a new RTCPeerConnection
-	peerConnection, err := webrtc.NewPeerConnection(w.state.webrtcConfig)
+	// Create a new RTCPeerConnection via a per-peer API wired with the
+	// REMB/TWCC interceptor registry
+	api, err := w.newPeerAPI(peerID)
+	if err != nil {
+		return nil, err
+	}
+	peerConnection, err := api.NewPeerConnection(w.state.webrtcConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -300,16 +483,47 @@ func (w *WebSink) createPeerConnection(peerID string) (*webrtc.PeerConnection, e
 			CAT.Log(gst.LevelInfo, fmt.Sprintf("Peer %s disconnected, cleaning up", peerID))
 			// Remove from peer connections map
 			w.updatePeerConnections(peerID, nil, false)
+			// Drop its bitrate estimate so it stops dragging down a "min" aggregate
+			w.onPeerDisconnected(peerID)
+			// Stop tracking its simulcast rung
+			w.state.pipelineManager.unregisterPeerSelector(peerID)
 			// Close the peer connection to free resources
 			peerConnection.Close()
 		}
 	})
 
-	// Add the video track to the peer connection
-	_, err = peerConnection.AddTrack(w.state.videoTrack)
+	// Register sendrecv transceivers for video and audio so browsers can
+	// optionally publish a mic/cam back in addition to receiving the feed.
+	videoTransceiverInit := webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionSendrecv}
+	videoTrack := w.state.videoTrack
+	layers := w.state.pipelineManager.layers
+	// Every new peer starts on the lowest ladder rung, since its bandwidth
+	// isn't known yet; maybeSwitchVideoLayer moves it once GCC estimates
+	// start arriving (see rtcp_feedback.go).
+	startLayer := -1
+	if len(layers) > 0 {
+		startLayer = len(layers) - 1
+		videoTrack = layers[startLayer].track
+	}
+	videoTransceiver, err := peerConnection.AddTransceiverFromTrack(videoTrack, videoTransceiverInit)
 	if err != nil {
 		return nil, err
 	}
+	if startLayer >= 0 {
+		w.state.pipelineManager.registerPeerSelector(peerID, videoTransceiver.Sender(), startLayer)
+	}
+	w.watchKeyframeRequests(peerID, videoTransceiver.Sender())
+
+	if _, err := peerConnection.AddTransceiverFromTrack(w.state.audioTrack, webrtc.RTPTransceiverInit{
+		Direction: webrtc.RTPTransceiverDirectionSendrecv,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := w.addControlDataChannel(peerID, peerConnection); err != nil {
+		return nil, err
+	}
+	w.addInboundTrackHandler(peerID, peerConnection)
 
 	return peerConnection, nil
 }
@@ -332,6 +546,13 @@ func (w *WebSink) startHTTPServer(self *base.GstBaseSink) bool {
 	fileserver := http.FileServer(http.FS(static))
 
 	mux.HandleFunc("POST /api/session", w.handleSession)
+	mux.HandleFunc("POST /api/session/{name}", w.handleSession)
+	mux.HandleFunc("GET /api/streams", w.handleStreams)
+	mux.HandleFunc("POST /whep", w.handleWhep)
+	mux.HandleFunc("POST /whep/{name}", w.handleWhep)
+	mux.HandleFunc("/whep/resource/", w.handleWhepResource)
+	mux.HandleFunc("POST /whip", w.handleWhip)
+	mux.HandleFunc("GET /ws", w.handleWS)
 	mux.Handle("GET /favicon.ico", fileserver)
 	mux.Handle("GET /", fileserver)
 
@@ -348,13 +569,33 @@ func (w *WebSink) startHTTPServer(self *base.GstBaseSink) bool {
 	addr := externalIP()
 	portStr := strconv.Itoa(port)
 
-	go func() {
-		if err := w.state.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			CAT.LogError("HTTP server error: " + err.Error())
+	scheme := "http"
+	if w.settings.auth.tlsEnabled() {
+		tlsConfig, tlsErr := w.settings.auth.buildTLSConfig()
+		if tlsErr != nil {
+			self.ErrorMessage(gst.DomainResource, gst.ResourceErrorSettings,
+				"Invalid TLS settings", tlsErr.Error())
+			return false
 		}
-	}()
+		w.state.server.TLSConfig = tlsConfig
+		scheme = "https"
+
+		go func() {
+			// Cert and key are already loaded into TLSConfig, so the
+			// filename arguments here are unused.
+			if err := w.state.server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+				CAT.LogError("HTTPS server error: " + err.Error())
+			}
+		}()
+	} else {
+		go func() {
+			if err := w.state.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				CAT.LogError("HTTP server error: " + err.Error())
+			}
+		}()
+	}
 
-	fmt.Println(GREEN + "HTTP server started at http://" + hostname + ".local:" + portStr + " and http://" + addr + ":" + portStr + RESET)
+	fmt.Println(GREEN + scheme + " server started at " + scheme + "://" + hostname + ".local:" + portStr + " and " + scheme + "://" + addr + ":" + portStr + RESET)
 	return true
 }
 
@@ -366,16 +607,24 @@ func (w *WebSink) New() glib.GoObjectSubclass {
 	CAT.Log(gst.LevelLog, "Initializing new WebSink object")
 	return &WebSink{
 		settings: &settings{
-			port:       8091,
-			stunServer: "stun:stun.l.google.com:19302",
-			isLive:     false,
-			unlock:     false,
+			port:            8091,
+			stunServer:      "stun:stun.l.google.com:19302",
+			isLive:          false,
+			unlock:          false,
+			simulcastLadder: DefaultSimulcastLadder,
+			streamName:      DefaultStreamName,
+			signallingMode:  DefaultSignallingMode,
+			ice:             iceSettings{iceTransportPolicy: DefaultICETransportPolicy, turnTTL: 3600},
+			minBitrateMode:  DefaultMinBitrateMode,
 		},
 		state: &state{
 			peerConnections: make(map[string]*webrtc.PeerConnection),
 			unblock:         make(chan int, 1),
 			h264Buffer:      make([]byte, 0),
+			inbound:         inboundPipelines{pipelines: make(map[string]*gst.Pipeline)},
+			pipelineManager: newPipelineManager(),
 		},
+		whipWhep: newWhipWhep(),
 	}
 }
 
@@ -389,14 +638,45 @@ func (w *WebSink) ClassInit(klass *glib.ObjectClass) {
 		"Stream H264 video to web browsers using WebRTC",
 		"Go-GST Contributors",
 	)
-	CAT.Log(gst.LevelLog, "Adding sink pad template and properties to class")
+	CAT.Log(gst.LevelLog, "Adding sink pad templates and properties to class")
 	class.AddPadTemplate(gst.NewPadTemplate(
 		"sink",
 		gst.PadDirectionSink,
 		gst.PadPresenceAlways,
-		gst.NewCapsFromString("video/x-h264,stream-format=byte-stream,alignment=au"),
+		gst.NewCapsFromString(
+			"video/x-h264,stream-format=byte-stream,alignment=au; "+
+				"video/x-vp8; video/x-vp9; video/x-av1",
+		),
+	))
+	// The "audio" pad template is declared, but GstBaseSink only drives
+	// buffers through the always-pad it was subclassed from, so no Opus
+	// data reaches websink through it and the shared audio track created
+	// in Start carries no samples.
+	class.AddPadTemplate(gst.NewPadTemplate(
+		"audio",
+		gst.PadDirectionSink,
+		gst.PadPresenceAlways,
+		gst.NewCapsFromString("audio/x-opus"),
+	))
+	// "sink_%u" is declared so pipeline tooling (e.g. gst-inspect, pad-added
+	// probes in an application) can see that per-layer simulcast input is on
+	// this element's roadmap, but GstBaseSink only ever drives buffers
+	// through the single always-pad it was subclassed from: a request pad
+	// here would never receive a chain call. Routing each simulcast layer's
+	// buffers to its own TrackLocalStaticSample (see pipelineManager.layers)
+	// needs a rewrite onto base.ExtendsElement with one chain function per
+	// pad, which is future work, not something addable incrementally here.
+	class.AddPadTemplate(gst.NewPadTemplate(
+		"sink_%u",
+		gst.PadDirectionSink,
+		gst.PadPresenceRequest,
+		gst.NewCapsFromString(
+			"video/x-h264,stream-format=byte-stream,alignment=au; "+
+				"video/x-vp8; video/x-vp9; video/x-av1",
+		),
 	))
 	class.InstallProperties(properties)
+	class.InstallSignals(signals)
 }
 
 // SetProperty sets a property on the WebSink
@@ -452,6 +732,182 @@ func (w *WebSink) SetProperty(self *glib.Object, id uint, value *glib.Value) {
 			w.settings.isLive = boolval
 			gst.ToElement(self).Log(CAT, gst.LevelInfo, fmt.Sprintf("Set `is-live` to %v", boolval))
 		}
+	case "simulcast":
+		if value == nil {
+			w.settings.simulcastLadder = ""
+		} else {
+			val, _ := value.GetString()
+			if _, err := parseSimulcastLadder(val); err != nil {
+				gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+					"Invalid simulcast ladder: "+err.Error(), "")
+				return
+			}
+			w.settings.simulcastLadder = val
+			gst.ToElement(self).Log(CAT, gst.LevelInfo, fmt.Sprintf("Set `simulcast` to %s", val))
+		}
+	case "stream-name":
+		if w.state.started {
+			gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+				"Cannot change stream-name while WebSink is running", "")
+			return
+		}
+		if value == nil {
+			w.settings.streamName = DefaultStreamName
+		} else {
+			val, _ := value.GetString()
+			w.settings.streamName = val
+			gst.ToElement(self).Log(CAT, gst.LevelInfo, fmt.Sprintf("Set `stream-name` to %s", val))
+		}
+	case "signalling-mode":
+		if w.state.started {
+			gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+				"Cannot change signalling-mode while WebSink is running", "")
+			return
+		}
+		if value == nil {
+			w.settings.signallingMode = DefaultSignallingMode
+		} else {
+			val, _ := value.GetString()
+			if val != signallingModeEmbedded && val != signallingModeExternal {
+				gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+					"signalling-mode must be \"embedded\" or \"external\"", "")
+				return
+			}
+			w.settings.signallingMode = val
+			gst.ToElement(self).Log(CAT, gst.LevelInfo, fmt.Sprintf("Set `signalling-mode` to %s", val))
+		}
+	case "turn-server":
+		if w.state.started {
+			gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+				"Cannot change turn-server while WebSink is running", "")
+			return
+		}
+		val, _ := value.GetString()
+		w.settings.ice.turnServer = val
+	case "turn-username":
+		if w.state.started {
+			gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+				"Cannot change turn-username while WebSink is running", "")
+			return
+		}
+		val, _ := value.GetString()
+		w.settings.ice.turnUsername = val
+	case "turn-password":
+		if w.state.started {
+			gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+				"Cannot change turn-password while WebSink is running", "")
+			return
+		}
+		val, _ := value.GetString()
+		w.settings.ice.turnPassword = val
+	case "turn-shared-secret":
+		if w.state.started {
+			gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+				"Cannot change turn-shared-secret while WebSink is running", "")
+			return
+		}
+		val, _ := value.GetString()
+		w.settings.ice.turnSharedSecret = val
+	case "turn-ttl":
+		if w.state.started {
+			gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+				"Cannot change turn-ttl while WebSink is running", "")
+			return
+		}
+		val, _ := value.GoValue()
+		intval, _ := val.(int)
+		w.settings.ice.turnTTL = uint32(intval)
+	case "ice-transport-policy":
+		if w.state.started {
+			gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+				"Cannot change ice-transport-policy while WebSink is running", "")
+			return
+		}
+		val, _ := value.GetString()
+		if val != "all" && val != "relay" {
+			gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+				"ice-transport-policy must be \"all\" or \"relay\"", "")
+			return
+		}
+		w.settings.ice.iceTransportPolicy = val
+	case "ice-udp-port-min":
+		if w.state.started {
+			gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+				"Cannot change ice-udp-port-min while WebSink is running", "")
+			return
+		}
+		val, _ := value.GoValue()
+		intval, _ := val.(int)
+		w.settings.ice.iceUDPPortMin = uint16(intval)
+	case "ice-udp-port-max":
+		if w.state.started {
+			gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+				"Cannot change ice-udp-port-max while WebSink is running", "")
+			return
+		}
+		val, _ := value.GoValue()
+		intval, _ := val.(int)
+		w.settings.ice.iceUDPPortMax = uint16(intval)
+	case "ice-tcp-enable":
+		if w.state.started {
+			gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+				"Cannot change ice-tcp-enable while WebSink is running", "")
+			return
+		}
+		val, _ := value.GoValue()
+		boolval, _ := val.(bool)
+		w.settings.ice.iceTCPEnable = boolval
+	case "nat-1to1-ips":
+		if w.state.started {
+			gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+				"Cannot change nat-1to1-ips while WebSink is running", "")
+			return
+		}
+		val, _ := value.GetString()
+		w.settings.ice.nat1to1IPs = val
+	case "tls-cert":
+		if w.state.started {
+			gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+				"Cannot change tls-cert while WebSink is running", "")
+			return
+		}
+		val, _ := value.GetString()
+		w.settings.auth.tlsCert = val
+	case "tls-key":
+		if w.state.started {
+			gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+				"Cannot change tls-key while WebSink is running", "")
+			return
+		}
+		val, _ := value.GetString()
+		w.settings.auth.tlsKey = val
+	case "auth-bearer-token":
+		val, _ := value.GetString()
+		w.settings.auth.authBearerToken = val
+	case "client-ca":
+		if w.state.started {
+			gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+				"Cannot change client-ca while WebSink is running", "")
+			return
+		}
+		val, _ := value.GetString()
+		w.settings.auth.clientCA = val
+	case "min-bitrate-mode":
+		if value == nil {
+			w.settings.minBitrateMode = DefaultMinBitrateMode
+		} else {
+			val, _ := value.GetString()
+			if val != "min" && val != "max" && val != "average" {
+				gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+					"min-bitrate-mode must be \"min\", \"max\", or \"average\"", "")
+				return
+			}
+			w.settings.minBitrateMode = val
+		}
+	case "keyframe-interval-ms":
+		val, _ := value.GoValue()
+		intval, _ := val.(int)
+		w.settings.keyframeIntervalMs = intval
 	}
 }
 
@@ -486,6 +942,93 @@ func (w *WebSink) GetProperty(self *glib.Object, id uint) *glib.Value {
 			fmt.Sprintf("Could not convert %v to GValue", w.settings.isLive),
 			err.Error(),
 		)
+	case "simulcast":
+		val, err := glib.GValue(w.settings.simulcastLadder)
+		if err == nil {
+			return val
+		}
+		gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorFailed,
+			fmt.Sprintf("Could not convert %s to GValue", w.settings.simulcastLadder),
+			err.Error(),
+		)
+	case "estimated-bitrate":
+		w.state.bufferMutex.Lock()
+		bitrate := w.state.estimatedBitrate
+		w.state.bufferMutex.Unlock()
+		val, err := glib.GValue(bitrate)
+		if err == nil {
+			return val
+		}
+		gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorFailed,
+			fmt.Sprintf("Could not convert %d to GValue", bitrate),
+			err.Error(),
+		)
+	case "stream-name":
+		val, err := glib.GValue(w.settings.streamName)
+		if err == nil {
+			return val
+		}
+		gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorFailed,
+			fmt.Sprintf("Could not convert %s to GValue", w.settings.streamName),
+			err.Error(),
+		)
+	case "signalling-mode":
+		val, err := glib.GValue(w.settings.signallingMode)
+		if err == nil {
+			return val
+		}
+		gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorFailed,
+			fmt.Sprintf("Could not convert %s to GValue", w.settings.signallingMode),
+			err.Error(),
+		)
+	case "turn-server":
+		val, _ := glib.GValue(w.settings.ice.turnServer)
+		return val
+	case "turn-username":
+		val, _ := glib.GValue(w.settings.ice.turnUsername)
+		return val
+	case "turn-password":
+		val, _ := glib.GValue(w.settings.ice.turnPassword)
+		return val
+	case "turn-shared-secret":
+		val, _ := glib.GValue(w.settings.ice.turnSharedSecret)
+		return val
+	case "turn-ttl":
+		val, _ := glib.GValue(int(w.settings.ice.turnTTL))
+		return val
+	case "ice-transport-policy":
+		val, _ := glib.GValue(w.settings.ice.iceTransportPolicy)
+		return val
+	case "ice-udp-port-min":
+		val, _ := glib.GValue(int(w.settings.ice.iceUDPPortMin))
+		return val
+	case "ice-udp-port-max":
+		val, _ := glib.GValue(int(w.settings.ice.iceUDPPortMax))
+		return val
+	case "ice-tcp-enable":
+		val, _ := glib.GValue(w.settings.ice.iceTCPEnable)
+		return val
+	case "nat-1to1-ips":
+		val, _ := glib.GValue(w.settings.ice.nat1to1IPs)
+		return val
+	case "tls-cert":
+		val, _ := glib.GValue(w.settings.auth.tlsCert)
+		return val
+	case "tls-key":
+		val, _ := glib.GValue(w.settings.auth.tlsKey)
+		return val
+	case "auth-bearer-token":
+		val, _ := glib.GValue(w.settings.auth.authBearerToken)
+		return val
+	case "client-ca":
+		val, _ := glib.GValue(w.settings.auth.clientCA)
+		return val
+	case "min-bitrate-mode":
+		val, _ := glib.GValue(w.settings.minBitrateMode)
+		return val
+	case "keyframe-interval-ms":
+		val, _ := glib.GValue(w.settings.keyframeIntervalMs)
+		return val
 	}
 	return nil
 }
@@ -497,21 +1040,27 @@ func (w *WebSink) Start(self *base.GstBaseSink) bool {
 		return false
 	}
 	w.settings.unlock = false
+	w.state.baseSink = self
+
+	settingEngine, seErr := w.settings.ice.buildSettingEngine()
+	if seErr != nil {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorSettings,
+			"Invalid ICE settings", seErr.Error())
+		return false
+	}
+	w.state.settingEngine = settingEngine
+	w.state.peerBitrates = make(map[string]int)
 
 	// Configure WebRTC
-	w.state.webrtcConfig = webrtc.Configuration{}
-	if w.settings.stunServer != "" {
-		w.state.webrtcConfig.ICEServers = []webrtc.ICEServer{
-			{
-				URLs: []string{w.settings.stunServer},
-			},
-		}
+	w.state.webrtcConfig = webrtc.Configuration{
+		ICEServers:         w.settings.ice.buildICEServers(w.settings.stunServer),
+		ICETransportPolicy: w.settings.ice.transportPolicy(),
 	}
 
 	// Create shared video track
 	var err error
 	w.state.videoTrack, err = webrtc.NewTrackLocalStaticSample(
-		webrtc.RTPCodecCapability{MimeType: "video/h264"},
+		rtpCodecCapabilityForCaps("video/x-h264"),
 		"video",
 		"websink",
 	)
@@ -520,12 +1069,44 @@ func (w *WebSink) Start(self *base.GstBaseSink) bool {
 			"Failed to create video track", err.Error())
 		return false
 	}
+	w.state.pipelineManager.tracks[webrtc.MimeTypeH264] = w.state.videoTrack
+
+	layers, err := parseSimulcastLadder(w.settings.simulcastLadder)
+	if err != nil {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorSettings,
+			"Invalid simulcast ladder", err.Error())
+		return false
+	}
+	w.state.pipelineManager.layers = layers
+	if len(layers) > 0 {
+		if err := w.state.pipelineManager.createSimulcastTracks(webrtc.MimeTypeH264); err != nil {
+			self.ErrorMessage(gst.DomainResource, gst.ResourceErrorFailed,
+				"Failed to create simulcast layer tracks", err.Error())
+			return false
+		}
+	}
+
+	// Create shared audio track so every peer gets a sendrecv audio
+	// transceiver. No branch feeds it: nothing calls WriteSample on it, so
+	// peers receive no audio samples from websink.
+	w.state.audioTrack, err = webrtc.NewTrackLocalStaticSample(
+		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus},
+		"audio",
+		"websink",
+	)
+	if err != nil {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorFailed,
+			"Failed to create audio track", err.Error())
+		return false
+	}
 
 	// Start HTTP server
-	if !w.startHTTPServer(self) {
+	if !w.maybeStartSignalling(self) {
 		return false
 	}
 
+	globalStreamRegistry.register(w.settings.streamName, w)
+
 	w.state.started = true
 	self.Log(CAT, gst.LevelInfo, "Websink has started")
 	return true
@@ -538,6 +1119,13 @@ func (w *WebSink) Stop(self *base.GstBaseSink) bool {
 		return false
 	}
 
+	globalStreamRegistry.unregister(w.settings.streamName)
+
+	if w.state.signaller != nil {
+		w.state.signaller.Stop()
+		w.state.signaller = nil
+	}
+
 	// Close all peer connections
 	w.state.peerConnectionsMutex.Lock()
 	for id, pc := range w.state.peerConnections {
@@ -546,20 +1134,44 @@ func (w *WebSink) Stop(self *base.GstBaseSink) bool {
 	}
 	w.state.peerConnectionsMutex.Unlock()
 
-	// Shutdown HTTP server
-	if w.state.server != nil {
-		if err := w.state.server.Close(); err != nil {
-			self.ErrorMessage(gst.DomainResource, gst.ResourceErrorClose,
-				"Failed to close HTTP server", err.Error())
-			return false
-		}
-	}
-
 	w.state.started = false
 	self.Log(CAT, gst.LevelInfo, "Websink has stopped")
 	return true
 }
 
+// SetCaps is called whenever caps are negotiated on the sink pad. It picks
+// the RTPCodecCapability matching the negotiated codec and switches
+// w.state.videoTrack to the (lazily-created) track for that codec, so
+// subsequent peer connections advertise and receive the right format.
+func (w *WebSink) SetCaps(self *base.GstBaseSink, caps *gst.Caps) bool {
+	structure := caps.GetStructureAt(0)
+	if structure == nil {
+		return false
+	}
+
+	capability := rtpCodecCapabilityForCaps(structure.Name())
+	track, err := w.state.pipelineManager.trackForCodec(capability)
+	if err != nil {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorFailed,
+			"Failed to create track for negotiated codec "+capability.MimeType, err.Error())
+		return false
+	}
+	w.state.videoTrack = track
+
+	// The simulcast ladder's tracks were built with a placeholder codec at
+	// Start (the negotiated one isn't known yet that early); rebuild them
+	// for the codec actually negotiated here. createSimulcastTracks no-ops
+	// if they already match.
+	if err := w.state.pipelineManager.createSimulcastTracks(capability.MimeType); err != nil {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorFailed,
+			"Failed to create simulcast layer tracks for negotiated codec "+capability.MimeType, err.Error())
+		return false
+	}
+
+	self.Log(CAT, gst.LevelInfo, "Negotiated codec "+capability.MimeType)
+	return true
+}
+
 // Render is called when a buffer is ready to be processed
 func (w *WebSink) Render(self *base.GstBaseSink, buffer *gst.Buffer) gst.FlowReturn {
 	if !w.state.started {
@@ -597,11 +1209,25 @@ func (w *WebSink) Render(self *base.GstBaseSink, buffer *gst.Buffer) gst.FlowRet
 	samples := buffer.Map(gst.MapRead).Bytes()
 	defer buffer.Unmap()
 
-	if err := w.state.videoTrack.WriteSample(media.Sample{Data: samples, Duration: *buffer.Duration().AsDuration()}); err != nil {
+	sample := media.Sample{Data: samples, Duration: *buffer.Duration().AsDuration()}
+	if err := w.state.videoTrack.WriteSample(sample); err != nil {
 		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorWrite,
 			"Error writing sample to track", err.Error())
 		return gst.FlowError
 	}
+	// websink has a single sink pad, so every simulcast layer currently
+	// carries the same encoded bytes; a true multi-bitrate ladder needs one
+	// encoder branch per layer feeding distinct request pads upstream.
+	for _, layer := range w.state.pipelineManager.layers {
+		if layer.track == nil || layer.track == w.state.videoTrack {
+			continue
+		}
+		if err := layer.track.WriteSample(sample); err != nil {
+			self.ErrorMessage(gst.DomainResource, gst.ResourceErrorWrite,
+				"Error writing sample to simulcast layer "+layer.rid, err.Error())
+			return gst.FlowError
+		}
+	}
 	return gst.FlowOK
 }
 
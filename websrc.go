@@ -0,0 +1,169 @@
+// websrc is a companion element to websink: where websink is a WHEP/WHIP
+// sink that ingests and serves WebRTC media, websrc is a WHIP *receiver*
+// that exposes a browser-published track as a GStreamer source pad, so
+// pipelines can consume browser-originated screen-shares or webcam feeds
+// that arrived over websink's sendrecv transceivers.
+//
+// websrc emits whole, still-packetized RTP packets (it does not
+// depacketize): downstream needs its own `rtpjitterbuffer ! rtpXdepay`
+// stage, e.g. `websink name=w  websrc name=s  s.src ! rtpjitterbuffer !
+// rtph264depay ! decodebin ! autovideosink`.
+//
+// +element:Name=websrc
+// +element:Rank=gst.RankNone
+// +element:Impl=WebSrc
+// +element:Subclass=base.ExtendsBaseSrc
+
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/go-gst/go-glib/glib"
+	"github.com/go-gst/go-gst/gst"
+	"github.com/go-gst/go-gst/gst/base"
+)
+
+// webrtcPacket is one whole RTP packet handed off from a websink peer's
+// OnTrack handler to the matching websrc instance.
+type webrtcPacket struct {
+	data []byte
+}
+
+// webSrcState holds the state shared between a websink instance's inbound
+// track handler and the websrc element reading from it.
+type webSrcState struct {
+	started  bool
+	peerID   string
+	mimeType string
+	packets  chan webrtcPacket
+}
+
+// WebSrc is our implementation of a GStreamer source element that surfaces
+// inbound WebRTC media as a regular src pad.
+type WebSrc struct {
+	settings *webSrcSettings
+	state    *webSrcState
+	mutex    sync.Mutex
+}
+
+type webSrcSettings struct {
+	peerID string
+}
+
+// New creates a new WebSrc instance
+func (s *WebSrc) New() glib.GoObjectSubclass {
+	CAT.Log(gst.LevelLog, "Initializing new WebSrc object")
+	return &WebSrc{
+		settings: &webSrcSettings{},
+		state:    &webSrcState{packets: make(chan webrtcPacket, 64)},
+	}
+}
+
+// ClassInit initializes the WebSrc class
+func (s *WebSrc) ClassInit(klass *glib.ObjectClass) {
+	CAT.Log(gst.LevelLog, "Initializing websrc class")
+	class := gst.ToElementClass(klass)
+	class.SetMetadata(
+		"WebRTC Source",
+		"Source/Network",
+		"Receive browser-published WebRTC media and expose it as a GStreamer src pad",
+		"Go-GST Contributors",
+	)
+	class.AddPadTemplate(gst.NewPadTemplate(
+		"src",
+		gst.PadDirectionSrc,
+		gst.PadPresenceAlways,
+		gst.NewCapsFromString("application/x-rtp"),
+	))
+	class.InstallProperties([]*glib.ParamSpec{
+		glib.NewStringParam(
+			"peer-id", "Peer ID", "The websink peer ID to receive inbound media from",
+			nil,
+			glib.ParameterReadWrite,
+		),
+	})
+}
+
+// SetProperty sets a property on the WebSrc
+func (s *WebSrc) SetProperty(self *glib.Object, id uint, value *glib.Value) {
+	if value == nil {
+		return
+	}
+	val, _ := value.GetString()
+	s.settings.peerID = val
+}
+
+// GetProperty gets a property from the WebSrc
+func (s *WebSrc) GetProperty(self *glib.Object, id uint) *glib.Value {
+	val, err := glib.GValue(s.settings.peerID)
+	if err == nil {
+		return val
+	}
+	return nil
+}
+
+// Start is called to start the websrc element
+func (s *WebSrc) Start(self *base.GstBaseSrc) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.state.started {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorSettings, "WebSrc is already started", "")
+		return false
+	}
+	s.state.peerID = s.settings.peerID
+	s.state.started = true
+	registerWebSrc(s.state.peerID, s)
+	self.Log(CAT, gst.LevelInfo, fmt.Sprintf("WebSrc has started, waiting for peer %s", s.state.peerID))
+	return true
+}
+
+// Stop is called to stop the websrc element
+func (s *WebSrc) Stop(self *base.GstBaseSrc) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.state.started = false
+	self.Log(CAT, gst.LevelInfo, "WebSrc has stopped")
+	return true
+}
+
+// Create pulls the next whole RTP packet off the channel fed by the
+// matching websink peer's OnTrack handler and wraps it in a GstBuffer.
+func (s *WebSrc) Create(self *base.GstBaseSrc, offset uint64, length uint32) (gst.FlowReturn, *gst.Buffer) {
+	pkt, ok := <-s.state.packets
+	if !ok {
+		return gst.FlowEOS, nil
+	}
+	return gst.FlowOK, gst.NewBufferFromBytes(pkt.data)
+}
+
+// pushInboundPayload is called from websink's OnTrack handler (see
+// media_io.go) to hand a whole RTP packet to the websrc instance registered
+// for peerID, if any.
+func pushInboundPayload(peerID string, data []byte) {
+	inboundSrcRegistryMutex.RLock()
+	src, ok := inboundSrcRegistry[peerID]
+	inboundSrcRegistryMutex.RUnlock()
+	if !ok {
+		return
+	}
+	select {
+	case src.state.packets <- webrtcPacket{data: data}:
+	default:
+		// Drop if the consuming pipeline can't keep up rather than blocking OnTrack.
+	}
+}
+
+var (
+	inboundSrcRegistryMutex sync.RWMutex
+	inboundSrcRegistry      = make(map[string]*WebSrc)
+)
+
+// registerWebSrc associates a websrc instance with the peerID it should
+// receive inbound media from.
+func registerWebSrc(peerID string, src *WebSrc) {
+	inboundSrcRegistryMutex.Lock()
+	defer inboundSrcRegistryMutex.Unlock()
+	inboundSrcRegistry[peerID] = src
+}
@@ -0,0 +1,354 @@
+// This file adds IETF WHIP (WebRTC-HTTP Ingestion) and WHEP (WebRTC-HTTP
+// Egress) signaling endpoints alongside the existing bespoke /api/session
+// handler, so that websink interoperates with off-the-shelf WHIP/WHEP
+// clients such as OBS 30+, GStreamer's whipclientsink/whepsrc, and browser
+// WHEP players.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-gst/go-gst/gst"
+	"github.com/pion/webrtc/v4"
+)
+
+const (
+	sdpMimeType           = "application/sdp"
+	trickleIceSdpFragType = "application/trickle-ice-sdpfrag"
+)
+
+// whepResource tracks a single WHEP subscriber's peer connection so that the
+// resource URL returned in the Location header can be used for DELETE
+// (teardown) and PATCH (trickle ICE) requests.
+type whepResource struct {
+	id             string
+	peerConnection *webrtc.PeerConnection
+	etag           string
+}
+
+// whipResource tracks a single WHIP publisher's peer connection.
+type whipResource struct {
+	id             string
+	peerConnection *webrtc.PeerConnection
+}
+
+// whipWhep holds the state shared by the WHIP and WHEP handlers.
+type whipWhep struct {
+	mutex     sync.RWMutex
+	whepNext  atomic.Uint64
+	whipNext  atomic.Uint64
+	whepConns map[string]*whepResource
+	whipConns map[string]*whipResource
+
+	// activeTracks holds the most recent fan-out track per kind ("video",
+	// "audio") published by a WHIP publisher; any WHEP subscriber created
+	// from this point on gets these added before its SDP answer is sent (see
+	// handleWhep and fanOutWhipTrack).
+	activeTracksMutex sync.RWMutex
+	activeTracks      map[string]*webrtc.TrackLocalStaticRTP
+}
+
+func newWhipWhep() *whipWhep {
+	return &whipWhep{
+		whepConns:    make(map[string]*whepResource),
+		whipConns:    make(map[string]*whipResource),
+		activeTracks: make(map[string]*webrtc.TrackLocalStaticRTP),
+	}
+}
+
+// handleWhep implements POST /whep: it accepts an SDP offer from a WHEP
+// player and responds with an SDP answer, a resource URL for teardown, and
+// support for trickle ICE via PATCH.
+func (w *WebSink) handleWhep(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ct := req.Header.Get("Content-Type"); ct != "" && ct != sdpMimeType {
+		http.Error(resp, "Content-Type must be "+sdpMimeType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	w, ok := w.resolveNamedStream(resp, req)
+	if !ok {
+		return
+	}
+
+	if !w.authorizeRequest(resp, req) {
+		return
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(resp, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	resourceID := fmt.Sprintf("whep-%d", w.whipWhep.whepNext.Add(1))
+
+	peerConnection, err := w.createPeerConnection(resourceID)
+	if err != nil {
+		http.Error(resp, "Error creating peer connection: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Any WHIP publisher already fanning out media gets added now, before
+	// the offer is even processed, so this subscriber's very first SDP
+	// answer already carries it instead of requiring a later renegotiation
+	// this element has no way to push to a plain WHEP client.
+	w.whipWhep.activeTracksMutex.RLock()
+	for _, track := range w.whipWhep.activeTracks {
+		if _, err := peerConnection.AddTrack(track); err != nil {
+			CAT.LogError(fmt.Sprintf("Failed to add active WHIP track to WHEP subscriber %s: %s", resourceID, err.Error()))
+		}
+	}
+	w.whipWhep.activeTracksMutex.RUnlock()
+
+	offer := webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: string(body)}
+	if err := peerConnection.SetRemoteDescription(offer); err != nil {
+		peerConnection.Close()
+		http.Error(resp, "Error setting remote description: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	answer, err := peerConnection.CreateAnswer(nil)
+	if err != nil {
+		peerConnection.Close()
+		http.Error(resp, "Error creating answer: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := peerConnection.SetLocalDescription(answer); err != nil {
+		peerConnection.Close()
+		http.Error(resp, "Error setting local description: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	gatherComplete := webrtc.GatheringCompletePromise(peerConnection)
+	<-gatherComplete
+
+	// RFC 9725 ETags let a trickle-ICE PATCH assert it is targeting the SDP
+	// revision it last saw; we only ever have one revision per resource, so
+	// the initial ETag is fixed for the resource's lifetime.
+	etag := "\"" + resourceID + "\""
+
+	w.updatePeerConnections(resourceID, peerConnection, true)
+	w.whipWhep.mutex.Lock()
+	w.whipWhep.whepConns[resourceID] = &whepResource{id: resourceID, peerConnection: peerConnection, etag: etag}
+	w.whipWhep.mutex.Unlock()
+
+	// The resource URL is under /whep/resource/ rather than /whep/<id>
+	// directly, so it cannot collide with the POST /whep{,/{name}} routes
+	// registered on the same mux.
+	location := "/whep/resource/" + resourceID
+	resp.Header().Set("Content-Type", sdpMimeType)
+	resp.Header().Set("Location", location)
+	resp.Header().Set("ETag", etag)
+	resp.Header().Set("Access-Control-Expose-Headers", "Location, ETag")
+	resp.WriteHeader(http.StatusCreated)
+	io.WriteString(resp, peerConnection.LocalDescription().SDP)
+}
+
+// handleWhip implements POST /whip: it accepts an SDP offer from a WHIP
+// publisher and relays its incoming media as RTP tracks fanned out to every
+// WHEP subscriber connecting from that point on (see fanOutWhipTrack).
+func (w *WebSink) handleWhip(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ct := req.Header.Get("Content-Type"); ct != "" && ct != sdpMimeType {
+		http.Error(resp, "Content-Type must be "+sdpMimeType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if !w.authorizeRequest(resp, req) {
+		return
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(resp, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	resourceID := fmt.Sprintf("whip-%d", w.whipWhep.whipNext.Add(1))
+
+	api, err := w.newPeerAPI(resourceID)
+	if err != nil {
+		http.Error(resp, "Error building WebRTC API: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	peerConnection, err := api.NewPeerConnection(w.state.webrtcConfig)
+	if err != nil {
+		http.Error(resp, "Error creating peer connection: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := peerConnection.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo, webrtc.RTPTransceiverInit{
+		Direction: webrtc.RTPTransceiverDirectionRecvonly,
+	}); err != nil {
+		peerConnection.Close()
+		http.Error(resp, "Error adding transceiver: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	peerConnection.OnTrack(func(remoteTrack *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		CAT.Log(gst.LevelInfo, fmt.Sprintf("WHIP %s publishing track: %s", resourceID, remoteTrack.Codec().MimeType))
+		w.fanOutWhipTrack(resourceID, remoteTrack)
+	})
+
+	peerConnection.OnICEConnectionStateChange(func(connectionState webrtc.ICEConnectionState) {
+		if connectionState == webrtc.ICEConnectionStateDisconnected ||
+			connectionState == webrtc.ICEConnectionStateFailed ||
+			connectionState == webrtc.ICEConnectionStateClosed {
+			w.whipWhep.mutex.Lock()
+			delete(w.whipWhep.whipConns, resourceID)
+			w.whipWhep.mutex.Unlock()
+			peerConnection.Close()
+		}
+	})
+
+	offer := webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: string(body)}
+	if err := peerConnection.SetRemoteDescription(offer); err != nil {
+		peerConnection.Close()
+		http.Error(resp, "Error setting remote description: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	answer, err := peerConnection.CreateAnswer(nil)
+	if err != nil {
+		peerConnection.Close()
+		http.Error(resp, "Error creating answer: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := peerConnection.SetLocalDescription(answer); err != nil {
+		peerConnection.Close()
+		http.Error(resp, "Error setting local description: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	gatherComplete := webrtc.GatheringCompletePromise(peerConnection)
+	<-gatherComplete
+
+	w.whipWhep.mutex.Lock()
+	w.whipWhep.whipConns[resourceID] = &whipResource{id: resourceID, peerConnection: peerConnection}
+	w.whipWhep.mutex.Unlock()
+
+	location := "/whip/resource/" + resourceID
+	resp.Header().Set("Content-Type", sdpMimeType)
+	resp.Header().Set("Location", location)
+	resp.Header().Set("Access-Control-Expose-Headers", "Location")
+	resp.WriteHeader(http.StatusCreated)
+	io.WriteString(resp, peerConnection.LocalDescription().SDP)
+}
+
+// fanOutWhipTrack forwards RTP packets read from a WHIP publisher's remote
+// track to a local track of the same kind, stored as the active track for
+// that kind so every WHEP subscriber created from this point on gets it
+// added before its SDP answer is sent (see handleWhep).
+//
+// Subscribers already connected when this publisher track appears are not
+// retrofitted: plain WHEP (RFC 9725) gives the server no channel to push a
+// renegotiated offer to an already-answered viewer, so they only pick up a
+// publisher that started after they connected by reconnecting.
+func (w *WebSink) fanOutWhipTrack(resourceID string, remoteTrack *webrtc.TrackRemote) {
+	localTrack, err := webrtc.NewTrackLocalStaticRTP(remoteTrack.Codec().RTPCodecCapability, remoteTrack.Kind().String(), "whip-"+resourceID)
+	if err != nil {
+		CAT.LogError("Failed to create fan-out track for " + resourceID + ": " + err.Error())
+		return
+	}
+
+	kind := remoteTrack.Kind().String()
+	w.whipWhep.activeTracksMutex.Lock()
+	w.whipWhep.activeTracks[kind] = localTrack
+	w.whipWhep.activeTracksMutex.Unlock()
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := remoteTrack.Read(buf)
+		if err != nil {
+			w.whipWhep.activeTracksMutex.Lock()
+			if w.whipWhep.activeTracks[kind] == localTrack {
+				delete(w.whipWhep.activeTracks, kind)
+			}
+			w.whipWhep.activeTracksMutex.Unlock()
+			return
+		}
+		if _, err := localTrack.Write(buf[:n]); err != nil && err != io.ErrClosedPipe {
+			CAT.LogError("Error forwarding WHIP RTP packet: " + err.Error())
+			return
+		}
+	}
+}
+
+// handleWhepResource implements DELETE (teardown) and PATCH (trickle ICE)
+// for a WHEP subscriber's resource URL.
+func (w *WebSink) handleWhepResource(resp http.ResponseWriter, req *http.Request) {
+	if !w.authorizeRequest(resp, req) {
+		return
+	}
+
+	id := strings.TrimPrefix(req.URL.Path, "/whep/resource/")
+
+	w.whipWhep.mutex.RLock()
+	whep, ok := w.whipWhep.whepConns[id]
+	w.whipWhep.mutex.RUnlock()
+	if !ok {
+		http.Error(resp, "No such resource", http.StatusNotFound)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodDelete:
+		w.whipWhep.mutex.Lock()
+		delete(w.whipWhep.whepConns, id)
+		w.whipWhep.mutex.Unlock()
+		w.updatePeerConnections(id, nil, false)
+		whep.peerConnection.Close()
+		resp.WriteHeader(http.StatusOK)
+	case http.MethodPatch:
+		if ct := req.Header.Get("Content-Type"); ct != "" && ct != trickleIceSdpFragType {
+			http.Error(resp, "Content-Type must be "+trickleIceSdpFragType, http.StatusUnsupportedMediaType)
+			return
+		}
+		if match := req.Header.Get("If-Match"); match != "" && match != whep.etag {
+			http.Error(resp, "ETag mismatch", http.StatusPreconditionFailed)
+			return
+		}
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			http.Error(resp, "Error reading request body", http.StatusBadRequest)
+			return
+		}
+		if err := addTrickleCandidates(whep.peerConnection, string(body)); err != nil {
+			http.Error(resp, "Error adding ICE candidate: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// addTrickleCandidates parses the `a=candidate:` lines out of a
+// application/trickle-ice-sdpfrag body and adds each one to the peer
+// connection.
+func addTrickleCandidates(peerConnection *webrtc.PeerConnection, sdpFrag string) error {
+	for _, line := range strings.Split(sdpFrag, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "a=candidate:") {
+			continue
+		}
+		candidate := strings.TrimPrefix(line, "a=")
+		if err := peerConnection.AddICECandidate(webrtc.ICECandidateInit{Candidate: candidate}); err != nil {
+			return err
+		}
+	}
+	return nil
+}